@@ -0,0 +1,164 @@
+// Copyright © 2020 Harrison Brown harrybrown98@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/harrybrwn/yt/youtube"
+	"github.com/spf13/cobra"
+)
+
+// newFormatsCommand returns the `yt formats <id>` command, which
+// lists every itag available for a video so a user can pick one with
+// `--format` on the download commands.
+func newFormatsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "formats <id>",
+		Short: "List the available download formats for a video",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id := args[0]
+			if isurl(id) {
+				id = getid(id)
+			}
+			formats, err := youtube.GetFormats(id)
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ITAG\tQUALITY\tCODECS\tBITRATE\tSIZE\tMIME TYPE")
+			for _, f := range formats {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\n",
+					f.Itag, f.Quality, f.Codecs, f.Bitrate, humanSize(f.ContentLength), f.MimeType)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+// humanSize renders n bytes as a short human-readable size, e.g.
+// "12.3MB". It returns "?" for an unknown (zero) size.
+func humanSize(n int64) string {
+	if n <= 0 {
+		return "?"
+	}
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// selectFormat picks the best format out of formats matching the
+// given --format (itag), --quality, --video-codec, and --audio-codec
+// flags. An empty selector is ignored. format takes priority over the
+// rest since it names an exact itag. audioOnly restricts the
+// candidates to audio-only streams, as required by `yt audio`:
+// without it, quality/itag selection can just as easily land on a
+// video-only stream, which would be written out as if it were audio.
+func selectFormat(formats []youtube.Format, format, quality, videoCodec, audioCodec string, audioOnly bool) (youtube.Format, error) {
+	if audioOnly {
+		formats = audioOnlyFormats(formats)
+		if len(formats) == 0 {
+			return youtube.Format{}, fmt.Errorf("no audio-only formats available")
+		}
+	}
+
+	if format != "" {
+		itag, err := strconv.Atoi(format)
+		if err != nil {
+			return youtube.Format{}, fmt.Errorf("invalid --format %q: %w", format, err)
+		}
+		for _, f := range formats {
+			if f.Itag == itag {
+				return f, nil
+			}
+		}
+		if audioOnly {
+			return youtube.Format{}, fmt.Errorf("no audio-only format with itag %d", itag)
+		}
+		return youtube.Format{}, fmt.Errorf("no format with itag %d", itag)
+	}
+
+	candidates := formats
+	if videoCodec != "" {
+		candidates = filterCodec(candidates, videoCodec)
+	}
+	if audioCodec != "" {
+		candidates = filterCodec(candidates, audioCodec)
+	}
+	if len(candidates) == 0 {
+		return youtube.Format{}, fmt.Errorf("no formats match the requested codecs")
+	}
+
+	switch quality {
+	case "", "best":
+		return bestOf(candidates, true), nil
+	case "worst":
+		return bestOf(candidates, false), nil
+	default:
+		for _, f := range candidates {
+			if f.Quality == quality {
+				return f, nil
+			}
+		}
+		return youtube.Format{}, fmt.Errorf("no format with quality %q", quality)
+	}
+}
+
+// audioOnlyFormats returns the formats that carry nothing but audio,
+// excluding progressive (combined video+audio) and video-only
+// streams.
+func audioOnlyFormats(formats []youtube.Format) []youtube.Format {
+	out := make([]youtube.Format, 0, len(formats))
+	for _, f := range formats {
+		if strings.HasPrefix(f.MimeType, "audio/") {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// filterCodec returns the formats whose Codecs field mentions codec.
+func filterCodec(formats []youtube.Format, codec string) []youtube.Format {
+	out := make([]youtube.Format, 0, len(formats))
+	for _, f := range formats {
+		if strings.Contains(strings.ToLower(f.Codecs), strings.ToLower(codec)) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// bestOf returns the highest (or lowest, if best is false) bitrate
+// format in formats.
+func bestOf(formats []youtube.Format, best bool) youtube.Format {
+	choice := formats[0]
+	for _, f := range formats[1:] {
+		if best == (f.Bitrate > choice.Bitrate) {
+			choice = f
+		}
+	}
+	return choice
+}
@@ -0,0 +1,136 @@
+// Copyright © 2020 Harrison Brown harrybrown98@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/harrybrwn/yt/youtube"
+)
+
+func testFormats() []youtube.Format {
+	return []youtube.Format{
+		{Itag: 18, Quality: "360p", Codecs: "avc1.42001E, mp4a.40.2", Bitrate: 500_000, HasAudio: true, MimeType: "video/mp4"},
+		{Itag: 22, Quality: "720p", Codecs: "avc1.64001F, mp4a.40.2", Bitrate: 2_000_000, HasAudio: true, MimeType: "video/mp4"},
+		{Itag: 137, Quality: "1080p", Codecs: "avc1.640028", Bitrate: 4_000_000, MimeType: "video/mp4"},
+		{Itag: 248, Quality: "1080p", Codecs: "vp9", Bitrate: 3_500_000, MimeType: "video/webm"},
+		{Itag: 139, Quality: "", Codecs: "mp4a.40.5", Bitrate: 48_000, HasAudio: true, MimeType: "audio/mp4"},
+		{Itag: 140, Quality: "", Codecs: "mp4a.40.2", Bitrate: 128_000, HasAudio: true, MimeType: "audio/mp4"},
+	}
+}
+
+func TestSelectFormatByItag(t *testing.T) {
+	f, err := selectFormat(testFormats(), "22", "", "", "", false)
+	if err != nil {
+		t.Fatalf("selectFormat: %v", err)
+	}
+	if f.Itag != 22 {
+		t.Errorf("selectFormat(format=22) = itag %d, want 22", f.Itag)
+	}
+}
+
+func TestSelectFormatUnknownItag(t *testing.T) {
+	if _, err := selectFormat(testFormats(), "9999", "", "", "", false); err == nil {
+		t.Fatal("selectFormat with an unknown itag returned nil error")
+	}
+}
+
+func TestSelectFormatBestDefaultsToHighestBitrate(t *testing.T) {
+	f, err := selectFormat(testFormats(), "", "", "", "", false)
+	if err != nil {
+		t.Fatalf("selectFormat: %v", err)
+	}
+	if f.Itag != 137 {
+		t.Errorf("selectFormat(quality=best) = itag %d, want 137 (highest bitrate)", f.Itag)
+	}
+}
+
+func TestSelectFormatWorst(t *testing.T) {
+	f, err := selectFormat(testFormats(), "", "worst", "", "", false)
+	if err != nil {
+		t.Fatalf("selectFormat: %v", err)
+	}
+	if f.Itag != 139 {
+		t.Errorf("selectFormat(quality=worst) = itag %d, want 139 (lowest bitrate overall)", f.Itag)
+	}
+}
+
+func TestSelectFormatByExactQuality(t *testing.T) {
+	f, err := selectFormat(testFormats(), "", "360p", "", "", false)
+	if err != nil {
+		t.Fatalf("selectFormat: %v", err)
+	}
+	if f.Itag != 18 {
+		t.Errorf("selectFormat(quality=360p) = itag %d, want 18", f.Itag)
+	}
+}
+
+func TestSelectFormatByVideoCodec(t *testing.T) {
+	f, err := selectFormat(testFormats(), "", "", "vp9", "", false)
+	if err != nil {
+		t.Fatalf("selectFormat: %v", err)
+	}
+	if f.Itag != 248 {
+		t.Errorf("selectFormat(video-codec=vp9) = itag %d, want 248", f.Itag)
+	}
+}
+
+func TestSelectFormatNoCodecMatch(t *testing.T) {
+	if _, err := selectFormat(testFormats(), "", "", "av1", "", false); err == nil {
+		t.Fatal("selectFormat with an unmatched codec returned nil error")
+	}
+}
+
+func TestSelectFormatAudioOnlyRestrictsToAudioStreams(t *testing.T) {
+	f, err := selectFormat(testFormats(), "", "best", "", "", true)
+	if err != nil {
+		t.Fatalf("selectFormat: %v", err)
+	}
+	if f.Itag != 140 {
+		t.Errorf("selectFormat(audioOnly, quality=best) = itag %d, want 140 (highest-bitrate audio-only stream)", f.Itag)
+	}
+}
+
+func TestSelectFormatAudioOnlyRejectsVideoItag(t *testing.T) {
+	if _, err := selectFormat(testFormats(), "137", "", "", "", true); err == nil {
+		t.Fatal("selectFormat(audioOnly) accepted a video-only itag, want an error")
+	}
+}
+
+func TestSelectFormatAudioOnlyNoAudioAvailable(t *testing.T) {
+	videoOnly := []youtube.Format{
+		{Itag: 137, Quality: "1080p", Codecs: "avc1.640028", Bitrate: 4_000_000, MimeType: "video/mp4"},
+	}
+	if _, err := selectFormat(videoOnly, "", "", "", "", true); err == nil {
+		t.Fatal("selectFormat(audioOnly) with no audio streams returned nil error")
+	}
+}
+
+func TestFilterCodecIsCaseInsensitive(t *testing.T) {
+	got := filterCodec(testFormats(), "AVC1")
+	if len(got) != 3 {
+		t.Fatalf("filterCodec(AVC1) returned %d formats, want 3", len(got))
+	}
+}
+
+func TestBestOfHighestAndLowest(t *testing.T) {
+	formats := testFormats()
+	if got := bestOf(formats, true); got.Itag != 137 {
+		t.Errorf("bestOf(best=true) = itag %d, want 137", got.Itag)
+	}
+	if got := bestOf(formats, false); got.Itag != 139 {
+		t.Errorf("bestOf(best=false) = itag %d, want 139", got.Itag)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright © 2020 Harrison Brown harrybrown98@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// journalPath returns the path to the resume journal, creating its
+// parent directory (~/.yt) if it does not already exist.
+func journalPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, ".yt")
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "journal.json"), nil
+}
+
+// journal records which video IDs have already been downloaded for a
+// given playlist or channel so that re-running a batch download can
+// skip items that finished in a previous, interrupted run.
+type journal struct {
+	mu   sync.Mutex
+	path string
+	Done map[string]map[string]bool `json:"done"` // list (playlist/channel) id -> video id -> done
+}
+
+// loadJournal reads the journal from ~/.yt/journal.json, returning an
+// empty journal if the file does not exist yet.
+func loadJournal() (*journal, error) {
+	path, err := journalPath()
+	if err != nil {
+		return nil, err
+	}
+	j := &journal{path: path, Done: make(map[string]map[string]bool)}
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(raw, j); err != nil {
+		return nil, err
+	}
+	if j.Done == nil {
+		j.Done = make(map[string]map[string]bool)
+	}
+	return j, nil
+}
+
+// IsDone reports whether id has already been recorded as downloaded
+// as part of list (a playlist or channel id).
+func (j *journal) IsDone(list, id string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.Done[list][id]
+}
+
+// MarkDone records id as downloaded for list and persists the journal
+// to disk immediately, so progress survives an interruption.
+func (j *journal) MarkDone(list, id string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.Done[list] == nil {
+		j.Done[list] = make(map[string]bool)
+	}
+	j.Done[list][id] = true
+	return j.save()
+}
+
+// save writes the journal to disk. Callers must hold j.mu.
+func (j *journal) save() error {
+	raw, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(j.path, raw, 0o644)
+}
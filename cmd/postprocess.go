@@ -0,0 +1,101 @@
+// Copyright © 2020 Harrison Brown harrybrown98@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/harrybrwn/yt/pkg/postprocess"
+	"github.com/harrybrwn/yt/youtube"
+)
+
+// muxWithBestAudio downloads the highest-bitrate audio-only format
+// for v into a temporary file and muxes it with the already
+// downloaded video-only stream at videoPath, replacing videoPath with
+// the muxed result.
+func muxWithBestAudio(pp postprocess.PostProcessor, v *youtube.Video, videoPath string) (string, error) {
+	formats, err := youtube.GetFormats(v.ID)
+	if err != nil {
+		return videoPath, err
+	}
+	audio := bestAudioOnly(formats)
+	if audio.Itag == 0 {
+		return videoPath, fmt.Errorf("no audio-only format available to mux with %s", videoPath)
+	}
+
+	audioPath := videoPath + ".audio.tmp"
+	if err := v.Download(audioPath, nil, nil, audio.Itag); err != nil {
+		return videoPath, err
+	}
+	defer os.Remove(audioPath)
+
+	// Keep videoPath's extension last so ffmpeg can infer the
+	// container from it.
+	muxedPath := withoutExt(videoPath) + ".muxed" + filepath.Ext(videoPath)
+	if err := pp.Mux(videoPath, audioPath, muxedPath); err != nil {
+		return videoPath, err
+	}
+	if err := os.Rename(muxedPath, videoPath); err != nil {
+		return videoPath, err
+	}
+	return videoPath, nil
+}
+
+// bestAudioOnly returns the highest-bitrate audio-only format, or the
+// zero Format if none is present.
+func bestAudioOnly(formats []youtube.Format) youtube.Format {
+	var best youtube.Format
+	for _, f := range formats {
+		if f.HasAudio && strings.HasPrefix(f.MimeType, "video/") {
+			continue // combined video+audio, not what we want here
+		}
+		if f.HasAudio && f.Bitrate > best.Bitrate {
+			best = f
+		}
+	}
+	return best
+}
+
+// extractAudio transcodes the raw audio download at rawPath into
+// format using ffmpeg, embedding v's title and uploader as metadata,
+// and returns the path to the finished file.
+func extractAudio(pp postprocess.PostProcessor, v *youtube.Video, rawPath, format string) (string, error) {
+	out := withoutExt(rawPath) + "." + format
+	opts := postprocess.AudioOptions{
+		Format: format,
+		Metadata: postprocess.Metadata{
+			Title:    v.Title,
+			Uploader: v.Author,
+		},
+	}
+	if err := pp.ExtractAudio(rawPath, out, opts); err != nil {
+		return rawPath, err
+	}
+	if out != rawPath {
+		os.Remove(rawPath)
+	}
+	return out, nil
+}
+
+// withoutExt strips the last "." extension from p, if any.
+func withoutExt(p string) string {
+	if i := strings.LastIndex(p, "."); i >= 0 {
+		return p[:i]
+	}
+	return p
+}
@@ -17,14 +17,22 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/harrybrwn/errs"
+	"github.com/harrybrwn/yt/pkg/postprocess"
+	"github.com/harrybrwn/yt/pkg/ratelimit"
+	"github.com/harrybrwn/yt/pkg/reporter"
 	"github.com/harrybrwn/yt/pkg/terminal"
 	"github.com/harrybrwn/yt/youtube"
 	"github.com/spf13/cobra"
@@ -74,6 +82,8 @@ func RootCommand() *cobra.Command {
 		newDownloadCommand("audio", "audio from youtube videos", ".mpa"),
 		playlistCmd,
 		newinfoCmd(true),
+		newServeCommand(),
+		newFormatsCommand(),
 		testCmd,
 		versionCmd,
 		completionCmd,
@@ -165,74 +175,242 @@ func newDownloadCommand(name, short, defaultExt string) *cobra.Command {
 			if err != nil {
 				return err
 			}
+			noResume, err := cmd.Flags().GetBool("no-resume")
+			if err != nil {
+				return err
+			}
+			asJSON, err := cmd.Flags().GetBool("json")
+			if err != nil {
+				return err
+			}
+			quiet, err := cmd.Flags().GetBool("quiet")
+			if err != nil {
+				return err
+			}
+			jobs, err := cmd.Flags().GetInt("jobs")
+			if err != nil {
+				return err
+			}
+			retries, err := cmd.Flags().GetInt("retries")
+			if err != nil {
+				return err
+			}
+			rateLimitStr, err := cmd.Flags().GetString("rate-limit")
+			if err != nil {
+				return err
+			}
+			bps, err := parseRateLimit(rateLimitStr)
+			if err != nil {
+				return err
+			}
+			limiter := ratelimit.NewLimiter(bps)
+			format, err := cmd.Flags().GetString("format")
+			if err != nil {
+				return err
+			}
+			quality, err := cmd.Flags().GetString("quality")
+			if err != nil {
+				return err
+			}
+			videoCodec, err := cmd.Flags().GetString("video-codec")
+			if err != nil {
+				return err
+			}
+			audioCodec, err := cmd.Flags().GetString("audio-codec")
+			if err != nil {
+				return err
+			}
+			audioFormat, err := cmd.Flags().GetString("audio-format")
+			if err != nil {
+				return err
+			}
 			path, err = filepath.Abs(path)
 			if err != nil {
 				return err
 			}
-			for i, arg := range args {
-				if isurl(arg) {
-					args[i] = getid(arg)
+
+			ids, lists, err := expandIDs(args)
+			if err != nil {
+				return err
+			}
+
+			var jr *journal
+			if !noResume && len(lists) > 0 {
+				jr, err = loadJournal()
+				if err != nil {
+					return err
 				}
+				ids = skipDone(jr, ids, lists)
 			}
 
-			err = handleVideos(args, func(v *youtube.Video) (err error) {
+			pp, ppErr := postprocess.NewFFmpeg()
+			if ppErr != nil {
+				log.Printf("post-processing disabled: %v", ppErr)
+			}
+
+			rep := newReporter(asJSON, quiet, cmd.OutOrStdout())
+			download := func(v *youtube.Video) (err error) {
+				start := time.Now()
 				p := filepath.Join(path, v.FileName) + ext
+				progress := func(bytes, total int64) {
+					rep.Progress(v.ID, bytes, total, time.Since(start))
+				}
+				itag := 0
+				var selected youtube.Format
+				if format != "" || quality != "" || videoCodec != "" || audioCodec != "" {
+					formats, ferr := youtube.GetFormats(v.ID)
+					if ferr != nil {
+						return ferr
+					}
+					selected, ferr = selectFormat(formats, format, quality, videoCodec, audioCodec, name == "audio")
+					if ferr != nil {
+						return ferr
+					}
+					itag = selected.Itag
+				}
 				switch name {
 				case "audio":
-					err = v.DownloadAudio(p)
+					err = v.DownloadAudio(p, progress, limiter, itag)
 				case "video":
-					err = v.Download(p)
+					err = v.Download(p, progress, limiter, itag)
 				default:
 					return errors.New("bad command name")
 				}
-				cmd.Printf("\r%s \"%s\"\n", terminal.Green("Downloaded"), v.FileName+ext)
-				return err
-			})
+				if err != nil {
+					return err
+				}
+
+				if pp != nil {
+					if name == "video" && itag != 0 && !selected.HasAudio {
+						p, err = muxWithBestAudio(pp, v, p)
+						if err != nil {
+							return err
+						}
+					}
+					if name == "audio" {
+						p, err = extractAudio(pp, v, p, audioFormat)
+						if err != nil {
+							return err
+						}
+					}
+				}
+
+				rep.Done(v.ID, p)
+				if jr != nil {
+					if list, ok := lists[v.ID]; ok {
+						if e := jr.MarkDone(list, v.ID); e != nil {
+							log.Println(e)
+						}
+					}
+				}
+				return nil
+			}
+			err = handleVideos(ids, rep, jobs, withRetry(download, retries))
 			return err
 		},
 	}
 	flags := c.Flags()
 	flags.StringP("extension", "e", defaultExt, "File extension used for video download")
+	flags.Bool("no-resume", false, "Ignore the resume journal and re-download everything in a playlist or channel")
+	flags.Bool("json", false, "Emit NDJSON progress events instead of interactive output")
+	flags.Bool("quiet", false, "Suppress all progress output")
+	flags.IntP("jobs", "j", 4, "Number of videos to download concurrently")
+	flags.String("rate-limit", "", "Cap aggregate download bandwidth, e.g. \"500K\" or \"2M\"")
+	flags.Int("retries", 0, "Number of times to retry a video after a transient failure")
+	flags.String("format", "", "Download a specific itag, see 'yt formats <id>'")
+	flags.String("quality", "", "Preferred quality, e.g. \"720p\", \"best\", or \"worst\" (default \"best\")")
+	flags.String("video-codec", "", "Restrict the selected format to a video codec, e.g. \"avc1\" or \"vp9\"")
+	flags.String("audio-codec", "", "Restrict the selected format to an audio codec, e.g. \"mp4a\" or \"opus\"")
+	flags.String("audio-format", "mp3", "Audio container/codec to transcode into with ffmpeg (mp3, opus, m4a)")
 	return c
 }
 
-const loadingInterval = time.Second / 5
+// newReporter picks the Reporter implementation for a download
+// command based on its --json/--quiet flags.
+func newReporter(asJSON, quiet bool, w io.Writer) reporter.Reporter {
+	switch {
+	case quiet:
+		return reporter.Quiet{}
+	case asJSON:
+		return reporter.JSON{W: w}
+	default:
+		return reporter.TTY{W: w}
+	}
+}
 
-func handleVideos(ids []string, fn videoHandler) (err error) {
+// expandIDs resolves args (single video ids/URLs, playlist URLs, and
+// channel URLs) into a flat list of video ids. lists maps each video
+// id that came from a playlist or channel back to that list's id, so
+// progress can be recorded in the resume journal.
+func expandIDs(args []string) (ids []string, lists map[string]string, err error) {
+	lists = make(map[string]string)
+	for _, arg := range args {
+		switch {
+		case isPlaylistURL(arg):
+			list := playlistID(arg)
+			vids, err := youtube.PlaylistVideos(list)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, v := range vids {
+				ids = append(ids, v)
+				lists[v] = list
+			}
+		case isChannelURL(arg):
+			list := channelID(arg)
+			vids, err := youtube.ChannelVideos(list)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, v := range vids {
+				ids = append(ids, v)
+				lists[v] = list
+			}
+		case isurl(arg):
+			ids = append(ids, getid(arg))
+		default:
+			ids = append(ids, arg)
+		}
+	}
+	return ids, lists, nil
+}
+
+// skipDone filters out video ids that the journal already has marked
+// as downloaded for their originating playlist or channel.
+func skipDone(jr *journal, ids []string, lists map[string]string) []string {
+	filtered := ids[:0]
+	for _, id := range ids {
+		if list, ok := lists[id]; ok && jr.IsDone(list, id) {
+			continue
+		}
+		filtered = append(filtered, id)
+	}
+	return filtered
+}
+
+// handleVideos resolves ids and runs fn for each of them, routing
+// progress and failures through rep rather than printing directly.
+func handleVideos(ids []string, rep reporter.Reporter, jobs int, fn videoHandler) (err error) {
 	if len(ids) == 0 {
 		return errors.New("no Arguments\n\nUse \"yt [command] --help\" for more information about a command")
 	}
 	setCursorOnHandler()
-	quit := make(chan struct{})
 	terminal.CursorOff()
 	defer terminal.CursorOn()
 
 	if len(ids) > 1 {
-		go func() {
-			err = asyncDownload(ids, fn)
-			close(quit)
-		}()
-	} else if len(ids) == 1 {
-		go func() {
-			var v *youtube.Video
-			defer close(quit)
-			v, err = youtube.NewVideo(ids[0])
-			if err != nil {
-				print("\r")
-				return
-			}
-			err = fn(v)
-		}()
+		return asyncDownload(ids, rep, jobs, fn)
 	}
-	for i := 0; ; i++ {
-		select {
-		case <-quit:
-			return err
-		default:
-			fmt.Printf("\r%s...  %c", terminal.Red("Downloading"), getLoadingChar(i))
-			time.Sleep(loadingInterval)
-		}
+	v, err := youtube.NewVideo(ids[0])
+	if err != nil {
+		return err
 	}
+	rep.Start(v.ID)
+	if err = fn(v); err != nil {
+		rep.Error(v.ID, err)
+		return err
+	}
+	return nil
 }
 
 func newinfoCmd(hidden bool) *cobra.Command {
@@ -307,30 +485,248 @@ func printfflags(info map[string][][]byte) error {
 	return nil
 }
 
-func asyncDownload(ids []string, fn videoHandler) (err error) {
-	var wg sync.WaitGroup
+// asyncDownload downloads ids concurrently, bounded to jobs
+// simultaneous workers. Every per-video failure is collected and
+// returned together as a multiError instead of racing a single err
+// variable.
+func asyncDownload(ids []string, rep reporter.Reporter, jobs int, fn videoHandler) error {
+	if jobs < 1 {
+		jobs = 1
+	}
+	sem := make(chan struct{}, jobs)
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs multiError
+	)
 	wg.Add(len(ids))
 	for _, id := range ids {
+		sem <- struct{}{}
 		go func(id string) {
 			defer wg.Done()
+			defer func() { <-sem }()
 			if isurl(id) {
 				id = getid(id)
 			}
-			v, err := youtube.NewVideo(id)
-			if err != nil {
-				log.Println(err)
+			v, e := youtube.NewVideo(id)
+			if e != nil {
+				rep.Error(id, e)
+				mu.Lock()
+				errs = append(errs, e)
+				mu.Unlock()
 				return
 			}
-			if e := fn(v); e != nil {
-				log.Println(e)
-				if err == nil {
-					err = e
-				}
+			rep.Start(v.ID)
+			if e = fn(v); e != nil {
+				rep.Error(v.ID, e)
+				mu.Lock()
+				errs = append(errs, e)
+				mu.Unlock()
 			}
 		}(id)
 	}
 	wg.Wait()
-	return err
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// multiError collects one error per failed download so a batch
+// operation reports every failure instead of only the last one.
+type multiError []error
+
+func (m multiError) Error() string {
+	strs := make([]string, len(m))
+	for i, e := range m {
+		strs[i] = e.Error()
+	}
+	return strings.Join(strs, "; ")
+}
+
+// withRetry wraps fn so transient failures (timeouts, connection
+// resets, 5xx responses) are retried with exponential backoff, up to
+// retries attempts beyond the first.
+func withRetry(fn videoHandler, retries int) videoHandler {
+	if retries <= 0 {
+		return fn
+	}
+	return func(v *youtube.Video) (err error) {
+		for attempt := 0; attempt <= retries; attempt++ {
+			if err = fn(v); err == nil || !isTransient(err) {
+				return err
+			}
+			time.Sleep(backoff(attempt))
+		}
+		return err
+	}
+}
+
+// backoff returns the delay before retry attempt, doubling each time
+// and capping at 30s.
+func backoff(attempt int) time.Duration {
+	d := time.Second << uint(attempt)
+	if d > 30*time.Second {
+		d = 30 * time.Second
+	}
+	return d
+}
+
+// isTransient reports whether err looks like a network or server
+// error worth retrying, as opposed to a permanent failure such as a
+// bad video id.
+func isTransient(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	msg := err.Error()
+	for _, s := range []string{"timeout", "connection reset", "EOF", " 500", " 502", " 503"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRateLimit parses a human bandwidth limit like "500K" or "2M"
+// into bytes per second. An empty string means unlimited (0).
+func parseRateLimit(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	mult := int64(1)
+	switch s[len(s)-1] {
+	case 'k', 'K':
+		mult = 1 << 10
+		s = s[:len(s)-1]
+	case 'm', 'M':
+		mult = 1 << 20
+		s = s[:len(s)-1]
+	case 'g', 'G':
+		mult = 1 << 30
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate limit %q: %w", s, err)
+	}
+	return n * mult, nil
+}
+
+// isurl reports whether s looks like a youtube video URL, as opposed
+// to a bare video id.
+func isurl(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return strings.Contains(u.Host, "youtube.com") || strings.Contains(u.Host, "youtu.be")
+}
+
+// getid extracts a video id out of a youtube URL, handling both the
+// youtube.com/watch?v=<id> and youtu.be/<id> short-link forms.
+func getid(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	if v := u.Query().Get("v"); v != "" {
+		return v
+	}
+	return strings.Trim(u.Path, "/")
+}
+
+// setCursorOnHandler makes sure an interrupted download (Ctrl-C)
+// still restores the terminal cursor before the process exits.
+func setCursorOnHandler() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	go func() {
+		<-c
+		terminal.CursorOn()
+		os.Exit(1)
+	}()
+}
+
+// isPlaylistURL reports whether s is a youtube playlist URL. A URL
+// that also carries a "v" param (e.g. a video watched from within a
+// playlist) is treated as a single-video URL instead, so downloading
+// one video doesn't silently pull in the whole playlist.
+func isPlaylistURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	q := u.Query()
+	return q.Get("list") != "" && q.Get("v") == ""
+}
+
+// playlistID extracts the playlist id from a playlist URL.
+func playlistID(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	return u.Query().Get("list")
+}
+
+// isChannelURL reports whether s is a youtube channel URL, e.g.
+// "https://www.youtube.com/channel/UCxxxx" or "https://www.youtube.com/c/name".
+func isChannelURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return strings.Contains(u.Path, "/channel/") || strings.Contains(u.Path, "/c/") || strings.Contains(u.Path, "/user/")
+}
+
+// channelID extracts the channel identifier (id, custom name, or
+// username) from a channel URL.
+func channelID(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i, p := range parts[:len(parts)-1] {
+		switch p {
+		case "channel", "c", "user":
+			return parts[i+1]
+		}
+	}
+	return parts[len(parts)-1]
+}
+
+// playlistCmd downloads every video in a youtube playlist as video
+// files. It predates expandIDs' automatic playlist-URL detection in
+// 'yt video'/'yt audio' and is kept as an explicit, single-purpose
+// entry point.
+var playlistCmd = &cobra.Command{
+	Use:   "playlist <url>",
+	Short: "Download every video in a youtube playlist",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		list := playlistID(args[0])
+		ids, err := youtube.PlaylistVideos(list)
+		if err != nil {
+			return err
+		}
+		path, err = filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		rep := newReporter(false, false, cmd.OutOrStdout())
+		download := func(v *youtube.Video) error {
+			p := filepath.Join(path, v.FileName) + ".mp4"
+			if err := v.Download(p, nil, nil, 0); err != nil {
+				return err
+			}
+			rep.Done(v.ID, p)
+			return nil
+		}
+		return handleVideos(ids, rep, 4, download)
+	},
 }
 
 var testCmd = &cobra.Command{
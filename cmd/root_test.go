@@ -0,0 +1,136 @@
+// Copyright © 2020 Harrison Brown harrybrown98@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/harrybrwn/yt/youtube"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	cases := []struct {
+		in   string
+		want int64
+	}{
+		{"", 0},
+		{"500", 500},
+		{"500K", 500 << 10},
+		{"2M", 2 << 20},
+		{"1g", 1 << 30},
+	}
+	for _, c := range cases {
+		got, err := parseRateLimit(c.in)
+		if err != nil {
+			t.Errorf("parseRateLimit(%q) returned error: %v", c.in, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("parseRateLimit(%q) = %d, want %d", c.in, got, c.want)
+		}
+	}
+}
+
+func TestParseRateLimitInvalid(t *testing.T) {
+	if _, err := parseRateLimit("nope"); err == nil {
+		t.Error("parseRateLimit(\"nope\") returned nil error, want a parse error")
+	}
+}
+
+func TestMultiErrorJoinsMessages(t *testing.T) {
+	errs := multiError{errors.New("a"), errors.New("b")}
+	if got, want := errs.Error(), "a; b"; got != want {
+		t.Errorf("multiError.Error() = %q, want %q", got, want)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("dial tcp: connection reset by peer"), true},
+		{errors.New("unexpected EOF"), true},
+		{errors.New("server responded with 503"), true},
+		{errors.New("no video with this id"), false},
+	}
+	for _, c := range cases {
+		if got := isTransient(c.err); got != c.want {
+			t.Errorf("isTransient(%q) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestWithRetryStopsAfterSuccess(t *testing.T) {
+	attempts := 0
+	fn := func(v *youtube.Video) error {
+		attempts++
+		if attempts < 2 {
+			return errors.New("timeout")
+		}
+		return nil
+	}
+	if err := withRetry(fn, 3)(nil); err != nil {
+		t.Fatalf("withRetry returned %v, want nil", err)
+	}
+	if attempts != 2 {
+		t.Errorf("fn called %d times, want 2", attempts)
+	}
+}
+
+func TestWithRetryDoesNotRetryPermanentErrors(t *testing.T) {
+	attempts := 0
+	fn := func(v *youtube.Video) error {
+		attempts++
+		return errors.New("no video with this id")
+	}
+	if err := withRetry(fn, 3)(nil); err == nil {
+		t.Fatal("withRetry returned nil error, want the permanent failure")
+	}
+	if attempts != 1 {
+		t.Errorf("fn called %d times, want 1 (no retries for a non-transient error)", attempts)
+	}
+}
+
+func TestWithRetryGivesUpAfterRetries(t *testing.T) {
+	attempts := 0
+	fn := func(v *youtube.Video) error {
+		attempts++
+		return errors.New("timeout")
+	}
+	if err := withRetry(fn, 2)(nil); err == nil {
+		t.Fatal("withRetry returned nil error, want the last failure")
+	}
+	if attempts != 3 {
+		t.Errorf("fn called %d times, want 3 (1 initial + 2 retries)", attempts)
+	}
+}
+
+func TestBackoffCapsAt30Seconds(t *testing.T) {
+	if got := backoff(10); got != 30*time.Second {
+		t.Errorf("backoff(10) = %v, want capped at 30s", got)
+	}
+}
+
+func TestIsPlaylistURLIgnoresWatchURLs(t *testing.T) {
+	if isPlaylistURL("https://www.youtube.com/watch?v=abc&list=PLxyz") {
+		t.Error("isPlaylistURL should not treat a watch URL with both v and list as a playlist URL")
+	}
+	if !isPlaylistURL("https://www.youtube.com/playlist?list=PLxyz") {
+		t.Error("isPlaylistURL should treat a playlist-only URL as a playlist URL")
+	}
+}
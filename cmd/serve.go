@@ -0,0 +1,131 @@
+// Copyright © 2020 Harrison Brown harrybrown98@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+	"github.com/harrybrwn/yt/pkg/queue"
+	"github.com/spf13/cobra"
+)
+
+// newServeCommand returns the `yt serve` command, a headless HTTP API
+// for enqueueing and tracking downloads so that other tools can drive
+// yt without an interactive terminal.
+func newServeCommand() *cobra.Command {
+	var (
+		addr string
+		jobs int
+	)
+	c := &cobra.Command{
+		Use:   "serve",
+		Short: "Run a headless download server",
+		Long:  `Run an HTTP server exposing a JSON API for enqueueing and tracking downloads.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			q := queue.New(jobs, dir)
+			defer q.Close()
+			cmd.Printf("listening on %s\n", addr)
+			return http.ListenAndServe(addr, newServeRouter(q))
+		},
+	}
+	flags := c.Flags()
+	flags.StringVar(&addr, "addr", ":8080", "Address for the download server to listen on")
+	flags.IntVar(&jobs, "jobs", 4, "Number of concurrent download workers")
+	return c
+}
+
+// newServeRouter builds the mux.Router exposing the download queue.
+func newServeRouter(q *queue.Queue) *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/downloads", listDownloads(q)).Methods(http.MethodGet)
+	r.HandleFunc("/downloads", enqueueDownload(q)).Methods(http.MethodPost)
+	r.HandleFunc("/downloads/{id}", getDownload(q)).Methods(http.MethodGet)
+	r.HandleFunc("/downloads/{id}", cancelDownload(q)).Methods(http.MethodDelete)
+	return r
+}
+
+type enqueueRequest struct {
+	ID string `json:"id"`
+}
+
+func enqueueDownload(q *queue.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req enqueueRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if isurl(req.ID) {
+			req.ID = getid(req.ID)
+		}
+		if req.ID == "" {
+			writeError(w, http.StatusBadRequest, errors.New("missing video id"))
+			return
+		}
+		job := q.Enqueue(req.ID)
+		writeJSON(w, http.StatusAccepted, job.Snapshot())
+	}
+}
+
+func listDownloads(q *queue.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, q.List())
+	}
+}
+
+func getDownload(q *queue.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		job, err := q.Get(id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	}
+}
+
+func cancelDownload(q *queue.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		if err := q.Cancel(id); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, queue.ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
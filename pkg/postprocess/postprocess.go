@@ -0,0 +1,142 @@
+// Copyright © 2020 Harrison Brown harrybrown98@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package postprocess turns the raw streams yt downloads into the
+// finished files a user actually wants: DASH video+audio muxed into
+// one container, audio transcoded to a real codec, and thumbnail or
+// metadata tags embedded. It is built around ffmpeg, detected on
+// PATH.
+package postprocess
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+)
+
+// Metadata is embedded into a processed file as container tags.
+type Metadata struct {
+	Title      string
+	Uploader   string
+	UploadDate string // YYYYMMDD
+}
+
+func (m Metadata) tags() map[string]string {
+	tags := make(map[string]string, 3)
+	if m.Title != "" {
+		tags["title"] = m.Title
+	}
+	if m.Uploader != "" {
+		tags["artist"] = m.Uploader
+	}
+	if m.UploadDate != "" {
+		tags["date"] = m.UploadDate
+	}
+	return tags
+}
+
+// AudioOptions configures ExtractAudio.
+type AudioOptions struct {
+	// Format is the target audio codec/container: "mp3", "opus", or
+	// "m4a" ("aac" is treated like "m4a"). Anything else leaves the
+	// audio stream untouched.
+	Format    string
+	Thumbnail string // optional path to a cover image to embed
+	Metadata  Metadata
+}
+
+// PostProcessor finishes a raw yt download into the file format a
+// user asked for.
+type PostProcessor interface {
+	// Mux combines a video-only stream and an audio-only stream into
+	// a single file at out, inferring the container from out's
+	// extension (.mp4 or .mkv).
+	Mux(videoPath, audioPath, out string) error
+	// ExtractAudio transcodes in (an audio-only download) to out
+	// according to opts, embedding a thumbnail and metadata tags
+	// when provided.
+	ExtractAudio(in, out string, opts AudioOptions) error
+}
+
+// FFmpeg is a PostProcessor backed by the ffmpeg binary found on
+// PATH.
+type FFmpeg struct {
+	// Bin is the path to the ffmpeg executable.
+	Bin string
+}
+
+// NewFFmpeg locates ffmpeg on PATH and returns a PostProcessor backed
+// by it.
+func NewFFmpeg() (*FFmpeg, error) {
+	bin, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("postprocess: ffmpeg not found on PATH: %w", err)
+	}
+	return &FFmpeg{Bin: bin}, nil
+}
+
+// Mux implements PostProcessor.
+func (f *FFmpeg) Mux(videoPath, audioPath, out string) error {
+	return f.run(
+		"-y",
+		"-i", videoPath,
+		"-i", audioPath,
+		"-c", "copy",
+		out,
+	)
+}
+
+// ExtractAudio implements PostProcessor.
+func (f *FFmpeg) ExtractAudio(in, out string, opts AudioOptions) error {
+	args := []string{"-y", "-i", in}
+	if opts.Thumbnail != "" {
+		args = append(args,
+			"-i", opts.Thumbnail,
+			"-map", "0:a", "-map", "1",
+			"-disposition:1", "attached_pic",
+		)
+	}
+	args = append(args, audioCodecArgs(opts.Format)...)
+	for k, v := range opts.Metadata.tags() {
+		args = append(args, "-metadata", fmt.Sprintf("%s=%s", k, v))
+	}
+	args = append(args, out)
+	return f.run(args...)
+}
+
+// audioCodecArgs returns the ffmpeg codec flags for a requested
+// audio format, copying the stream unchanged for an empty or
+// unrecognized format.
+func audioCodecArgs(format string) []string {
+	switch format {
+	case "mp3":
+		return []string{"-codec:a", "libmp3lame", "-q:a", "2"}
+	case "opus":
+		return []string{"-codec:a", "libopus"}
+	case "m4a", "aac":
+		return []string{"-codec:a", "aac"}
+	default:
+		return []string{"-codec:a", "copy"}
+	}
+}
+
+func (f *FFmpeg) run(args ...string) error {
+	cmd := exec.Command(f.Bin, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg %v: %w: %s", args, err, stderr.String())
+	}
+	return nil
+}
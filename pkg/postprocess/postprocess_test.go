@@ -0,0 +1,55 @@
+// Copyright © 2020 Harrison Brown harrybrown98@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postprocess
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAudioCodecArgs(t *testing.T) {
+	cases := []struct {
+		format string
+		want   []string
+	}{
+		{"mp3", []string{"-codec:a", "libmp3lame", "-q:a", "2"}},
+		{"opus", []string{"-codec:a", "libopus"}},
+		{"m4a", []string{"-codec:a", "aac"}},
+		{"aac", []string{"-codec:a", "aac"}},
+		{"", []string{"-codec:a", "copy"}},
+		{"wav", []string{"-codec:a", "copy"}},
+	}
+	for _, c := range cases {
+		if got := audioCodecArgs(c.format); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("audioCodecArgs(%q) = %v, want %v", c.format, got, c.want)
+		}
+	}
+}
+
+func TestMetadataTags(t *testing.T) {
+	m := Metadata{Title: "A Video", Uploader: "Someone", UploadDate: "20200101"}
+	tags := m.tags()
+	want := map[string]string{"title": "A Video", "artist": "Someone", "date": "20200101"}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags() = %v, want %v", tags, want)
+	}
+}
+
+func TestMetadataTagsOmitsEmptyFields(t *testing.T) {
+	tags := Metadata{Title: "Only Title"}.tags()
+	if len(tags) != 1 || tags["title"] != "Only Title" {
+		t.Errorf("tags() = %v, want only title set", tags)
+	}
+}
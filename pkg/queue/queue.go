@@ -0,0 +1,254 @@
+// Copyright © 2020 Harrison Brown harrybrown98@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package queue implements a small in-memory job queue used to drive
+// youtube downloads from a fixed-size pool of worker goroutines. It
+// backs both the headless `yt serve` HTTP API and, eventually, the
+// batch download commands.
+package queue
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/harrybrwn/yt/youtube"
+)
+
+// Status is the lifecycle state of a Job.
+type Status string
+
+// Job states.
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusDone      Status = "done"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+// ErrNotFound is returned when a job id is not known to the queue.
+var ErrNotFound = errors.New("queue: job not found")
+
+// ErrNotCancellable is returned when trying to cancel a job that has
+// already finished, failed, or been cancelled.
+var ErrNotCancellable = errors.New("queue: job is not cancellable")
+
+// Job represents a single download enqueued on the queue.
+type Job struct {
+	ID        string    `json:"id"`
+	VideoID   string    `json:"video_id"`
+	Status    Status    `json:"status"`
+	File      string    `json:"file,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	mu     sync.Mutex
+	cancel chan struct{}
+}
+
+// Snapshot returns a copy of the job's current state, safe to read
+// concurrently with the worker that is updating it.
+func (j *Job) Snapshot() Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return Job{
+		ID:        j.ID,
+		VideoID:   j.VideoID,
+		Status:    j.Status,
+		File:      j.File,
+		Error:     j.Error,
+		CreatedAt: j.CreatedAt,
+		UpdatedAt: j.UpdatedAt,
+	}
+}
+
+// Queue runs enqueued downloads across a fixed pool of workers.
+type Queue struct {
+	Dir     string // download directory for finished files
+	Workers int
+
+	mu     sync.Mutex
+	jobs   map[string]*Job
+	work   chan *Job
+	quit   chan struct{}
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// New creates a Queue with the given number of worker goroutines,
+// saving finished downloads into dir.
+func New(workers int, dir string) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	q := &Queue{
+		Dir:     dir,
+		Workers: workers,
+		jobs:    make(map[string]*Job),
+		work:    make(chan *Job, 64),
+		quit:    make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+	return q
+}
+
+// Enqueue schedules videoID for download and returns its Job.
+func (q *Queue) Enqueue(videoID string) *Job {
+	now := time.Now()
+	j := &Job{
+		ID:        uuid.New().String(),
+		VideoID:   videoID,
+		Status:    StatusQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+		cancel:    make(chan struct{}),
+	}
+	q.mu.Lock()
+	q.jobs[j.ID] = j
+	q.mu.Unlock()
+	q.work <- j
+	return j
+}
+
+// Get returns a snapshot of the job with the given id.
+func (q *Queue) Get(id string) (Job, error) {
+	q.mu.Lock()
+	j, ok := q.jobs[id]
+	q.mu.Unlock()
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return j.Snapshot(), nil
+}
+
+// List returns a snapshot of every job known to the queue, queued or
+// finished.
+func (q *Queue) List() []Job {
+	q.mu.Lock()
+	all := make([]*Job, 0, len(q.jobs))
+	for _, j := range q.jobs {
+		all = append(all, j)
+	}
+	q.mu.Unlock()
+	jobs := make([]Job, len(all))
+	for i, j := range all {
+		jobs[i] = j.Snapshot()
+	}
+	return jobs
+}
+
+// Cancel marks a queued or running job as cancelled. A queued job is
+// then skipped by its worker; a download already in progress is not
+// interrupted and runs to completion, but the job keeps its cancelled
+// status. It returns ErrNotCancellable if the job has already reached
+// a terminal state. Safe to call
+// concurrently, including multiple times for the same id (e.g. a
+// retried DELETE /downloads/{id}).
+func (q *Queue) Cancel(id string) error {
+	q.mu.Lock()
+	j, ok := q.jobs[id]
+	q.mu.Unlock()
+	if !ok {
+		return ErrNotFound
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	switch j.Status {
+	case StatusQueued, StatusRunning:
+		close(j.cancel)
+		j.Status = StatusCancelled
+		j.UpdatedAt = time.Now()
+		return nil
+	default:
+		return ErrNotCancellable
+	}
+}
+
+// Close stops the workers and waits for each to finish its current
+// job. Jobs still waiting in the queue stay queued. Enqueue does not
+// check whether the queue is closed, so a job enqueued after Close is
+// never run.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	q.mu.Unlock()
+	close(q.quit)
+	q.wg.Wait()
+}
+
+func (q *Queue) worker() {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.quit:
+			return
+		case j := <-q.work:
+			q.run(j)
+		}
+	}
+}
+
+func (q *Queue) run(j *Job) {
+	select {
+	case <-j.cancel:
+		return
+	default:
+	}
+	j.setStatus(StatusRunning, "")
+
+	v, err := youtube.NewVideo(j.VideoID)
+	if err != nil {
+		j.finish(StatusFailed, err.Error())
+		return
+	}
+	file := filepath.Join(q.Dir, v.FileName) + ".mp4"
+	if err = v.Download(file, nil, nil, 0); err != nil {
+		j.finish(StatusFailed, err.Error())
+		return
+	}
+	j.mu.Lock()
+	j.File = file
+	j.mu.Unlock()
+	j.finish(StatusDone, "")
+}
+
+// finish sets a terminal status, unless the job was cancelled while
+// the download was in flight, in which case the cancellation wins.
+func (j *Job) finish(s Status, errMsg string) {
+	select {
+	case <-j.cancel:
+		return
+	default:
+		j.setStatus(s, errMsg)
+	}
+}
+
+func (j *Job) setStatus(s Status, errMsg string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Status = s
+	j.Error = errMsg
+	j.UpdatedAt = time.Now()
+}
@@ -0,0 +1,128 @@
+// Copyright © 2020 Harrison Brown harrybrown98@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	"sync"
+	"testing"
+)
+
+func newTestQueue(jobs ...*Job) *Queue {
+	q := &Queue{jobs: make(map[string]*Job)}
+	for _, j := range jobs {
+		q.jobs[j.ID] = j
+	}
+	return q
+}
+
+func TestCancelQueuedJob(t *testing.T) {
+	j := &Job{ID: "a", Status: StatusQueued, cancel: make(chan struct{})}
+	q := newTestQueue(j)
+
+	if err := q.Cancel("a"); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if j.Status != StatusCancelled {
+		t.Errorf("Status = %s, want %s", j.Status, StatusCancelled)
+	}
+	select {
+	case <-j.cancel:
+	default:
+		t.Error("Cancel did not close j.cancel")
+	}
+}
+
+func TestCancelUnknownJob(t *testing.T) {
+	q := newTestQueue()
+	if err := q.Cancel("missing"); err != ErrNotFound {
+		t.Errorf("Cancel(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCancelTerminalJobFails(t *testing.T) {
+	for _, s := range []Status{StatusDone, StatusFailed, StatusCancelled} {
+		j := &Job{ID: "a", Status: s, cancel: make(chan struct{})}
+		q := newTestQueue(j)
+		if err := q.Cancel("a"); err != ErrNotCancellable {
+			t.Errorf("Cancel(status=%s) = %v, want ErrNotCancellable", s, err)
+		}
+	}
+}
+
+// TestCancelConcurrentCallsDoNotPanic is a regression test for two
+// concurrent Cancel calls on the same running/queued job (e.g. a
+// retried DELETE /downloads/{id}) racing to close j.cancel.
+func TestCancelConcurrentCallsDoNotPanic(t *testing.T) {
+	j := &Job{ID: "a", Status: StatusRunning, cancel: make(chan struct{})}
+	q := newTestQueue(j)
+
+	var wg sync.WaitGroup
+	results := make([]error, 10)
+	wg.Add(len(results))
+	for i := range results {
+		go func(i int) {
+			defer wg.Done()
+			results[i] = q.Cancel("a")
+		}(i)
+	}
+	wg.Wait()
+
+	var ok, failed int
+	for _, err := range results {
+		switch err {
+		case nil:
+			ok++
+		case ErrNotCancellable:
+			failed++
+		default:
+			t.Errorf("Cancel returned unexpected error: %v", err)
+		}
+	}
+	if ok != 1 || failed != len(results)-1 {
+		t.Errorf("got %d nil / %d ErrNotCancellable, want exactly 1 nil", ok, failed)
+	}
+}
+
+// TestFinishDoesNotOverwriteACancellation is a regression test for a
+// job that gets cancelled while its download is still in flight: the
+// worker must not clobber the cancellation with a terminal
+// done/failed status once the download eventually returns.
+func TestFinishDoesNotOverwriteACancellation(t *testing.T) {
+	j := &Job{ID: "a", Status: StatusCancelled, cancel: make(chan struct{})}
+	close(j.cancel)
+
+	j.finish(StatusDone, "")
+	if j.Status != StatusCancelled {
+		t.Errorf("Status = %s, want %s (cancellation must win)", j.Status, StatusCancelled)
+	}
+}
+
+func TestFinishSetsTerminalStatusWhenNotCancelled(t *testing.T) {
+	j := &Job{ID: "a", Status: StatusRunning, cancel: make(chan struct{})}
+
+	j.finish(StatusDone, "")
+	if j.Status != StatusDone {
+		t.Errorf("Status = %s, want %s", j.Status, StatusDone)
+	}
+}
+
+func TestFinishRecordsFailureError(t *testing.T) {
+	j := &Job{ID: "a", Status: StatusRunning, cancel: make(chan struct{})}
+
+	j.finish(StatusFailed, "boom")
+	if j.Status != StatusFailed || j.Error != "boom" {
+		t.Errorf("Status/Error = %s/%q, want %s/%q", j.Status, j.Error, StatusFailed, "boom")
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright © 2020 Harrison Brown harrybrown98@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit implements a shared token-bucket bandwidth cap
+// that multiple concurrent downloads can read through together.
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket capped at a fixed number of bytes per
+// second. A nil *Limiter, or one created with a non-positive rate, is
+// unlimited.
+type Limiter struct {
+	rate int64 // bytes per second
+
+	mu     sync.Mutex
+	tokens int64
+	last   time.Time
+}
+
+// NewLimiter returns a Limiter capped at bytesPerSecond. A
+// bytesPerSecond of 0 means unlimited.
+func NewLimiter(bytesPerSecond int64) *Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &Limiter{rate: bytesPerSecond, tokens: bytesPerSecond, last: time.Now()}
+}
+
+// WaitN blocks until n bytes worth of tokens are available, or ctx is
+// cancelled.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		wait := l.reserve(n)
+		if wait <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// reserve refills the bucket based on elapsed time and either takes n
+// tokens (returning 0) or reports how long the caller must wait.
+func (l *Limiter) reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += int64(now.Sub(l.last).Seconds() * float64(l.rate))
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+
+	if l.tokens >= int64(n) {
+		l.tokens -= int64(n)
+		return 0
+	}
+	need := int64(n) - l.tokens
+	return time.Duration(float64(need) / float64(l.rate) * float64(time.Second))
+}
+
+// Reader wraps an io.Reader, blocking each Read so that aggregate
+// throughput across every Reader sharing the same Limiter stays under
+// its configured rate.
+type Reader struct {
+	r   io.Reader
+	lim *Limiter
+	ctx context.Context
+}
+
+// NewReader wraps r with lim. A nil lim makes NewReader a no-op
+// passthrough.
+func NewReader(ctx context.Context, r io.Reader, lim *Limiter) *Reader {
+	return &Reader{r: r, lim: lim, ctx: ctx}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	// The bucket never holds more than rate tokens, so a read larger
+	// than that could never be paid for.
+	if r.lim != nil && int64(len(p)) > r.lim.rate {
+		p = p[:r.lim.rate]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 && r.lim != nil {
+		if werr := r.lim.WaitN(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
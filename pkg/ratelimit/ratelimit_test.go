@@ -0,0 +1,93 @@
+// Copyright © 2020 Harrison Brown harrybrown98@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestNewLimiterUnlimited(t *testing.T) {
+	if l := NewLimiter(0); l != nil {
+		t.Errorf("NewLimiter(0) = %v, want nil", l)
+	}
+	if l := NewLimiter(-1); l != nil {
+		t.Errorf("NewLimiter(-1) = %v, want nil", l)
+	}
+}
+
+func TestReserveTakesAvailableTokens(t *testing.T) {
+	l := NewLimiter(100)
+	if wait := l.reserve(50); wait != 0 {
+		t.Fatalf("reserve(50) with 100 tokens available = %v, want 0", wait)
+	}
+	if wait := l.reserve(50); wait != 0 {
+		t.Fatalf("reserve(50) with 50 tokens left = %v, want 0", wait)
+	}
+}
+
+func TestReserveBlocksWhenBucketEmpty(t *testing.T) {
+	l := NewLimiter(100)
+	l.reserve(100) // drain the bucket
+	wait := l.reserve(50)
+	if wait <= 0 {
+		t.Fatalf("reserve(50) on an empty 100 bytes/sec bucket = %v, want > 0", wait)
+	}
+	// Needing half the rate should cost roughly half a second.
+	if wait < 400*time.Millisecond || wait > 600*time.Millisecond {
+		t.Errorf("reserve(50) wait = %v, want ~500ms", wait)
+	}
+}
+
+func TestWaitNNilLimiterIsNoop(t *testing.T) {
+	var l *Limiter
+	if err := l.WaitN(context.Background(), 1<<30); err != nil {
+		t.Fatalf("nil Limiter.WaitN returned %v, want nil", err)
+	}
+}
+
+func TestWaitNRespectsContextCancellation(t *testing.T) {
+	l := NewLimiter(1) // 1 byte/sec: guaranteed to need to wait
+	l.reserve(1)       // drain the single token
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.WaitN(ctx, 100); err == nil {
+		t.Fatal("WaitN with a cancelled context returned nil error, want ctx.Err()")
+	}
+}
+
+func TestNewReaderNilLimiterPassesThrough(t *testing.T) {
+	r := NewReader(context.Background(), bytes.NewBufferString("hello"), nil)
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("ReadAll = %q, want %q", got, "hello")
+	}
+}
+
+func TestReaderReturnsUnderlyingEOF(t *testing.T) {
+	r := NewReader(context.Background(), bytes.NewReader(nil), NewLimiter(1000))
+	buf := make([]byte, 4)
+	_, err := r.Read(buf)
+	if err != io.EOF {
+		t.Errorf("Read on empty source = %v, want io.EOF", err)
+	}
+}
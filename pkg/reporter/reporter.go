@@ -0,0 +1,130 @@
+// Copyright © 2020 Harrison Brown harrybrown98@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package reporter defines pluggable ways of surfacing download
+// progress to a user or to another program driving yt as a
+// subprocess.
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/harrybrwn/yt/pkg/terminal"
+)
+
+// Event is the kind of progress update being reported.
+type Event string
+
+// Events emitted by a Reporter.
+const (
+	EventStart    Event = "start"
+	EventProgress Event = "progress"
+	EventDone     Event = "done"
+	EventError    Event = "error"
+)
+
+// Reporter receives progress updates for a set of in-flight
+// downloads, identified by video id.
+type Reporter interface {
+	// Start is called once a download for id begins.
+	Start(id string)
+	// Progress is called as bytes are written for id. total is 0 if
+	// unknown.
+	Progress(id string, bytes, total int64, elapsed time.Duration)
+	// Done is called once id finishes downloading to file.
+	Done(id, file string)
+	// Error is called if id fails to download.
+	Error(id string, err error)
+}
+
+// Tick is a single NDJSON progress record emitted by the JSON
+// reporter.
+type Tick struct {
+	Event   Event   `json:"event"`
+	ID      string  `json:"id"`
+	Bytes   int64   `json:"bytes,omitempty"`
+	Total   int64   `json:"total,omitempty"`
+	Elapsed float64 `json:"elapsed,omitempty"` // seconds
+	File    string  `json:"file,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// Quiet is a Reporter that discards every event.
+type Quiet struct{}
+
+func (Quiet) Start(string)                                 {}
+func (Quiet) Progress(string, int64, int64, time.Duration) {}
+func (Quiet) Done(string, string)                          {}
+func (Quiet) Error(string, error)                          {}
+
+// JSON is a Reporter that writes one NDJSON Tick per event to w,
+// suitable for another program to parse from yt's stdout.
+type JSON struct {
+	W io.Writer
+}
+
+func (j JSON) emit(t Tick) {
+	raw, err := json.Marshal(t)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(j.W, "%s\n", raw)
+}
+
+func (j JSON) Start(id string) {
+	j.emit(Tick{Event: EventStart, ID: id})
+}
+
+func (j JSON) Progress(id string, bytes, total int64, elapsed time.Duration) {
+	j.emit(Tick{Event: EventProgress, ID: id, Bytes: bytes, Total: total, Elapsed: elapsed.Seconds()})
+}
+
+func (j JSON) Done(id, file string) {
+	j.emit(Tick{Event: EventDone, ID: id, File: file})
+}
+
+func (j JSON) Error(id string, err error) {
+	j.emit(Tick{Event: EventError, ID: id, Error: err.Error()})
+}
+
+// TTY is a Reporter that renders a status line, with a percentage
+// when the total size is known, for a single active download at a
+// time, followed by yt's original "Downloaded" line.
+type TTY struct {
+	W io.Writer
+}
+
+func (t TTY) Start(id string) {
+	fmt.Fprintf(t.W, "\r%s %s...", terminal.Red("Downloading"), id)
+}
+
+func (t TTY) Progress(id string, bytes, total int64, elapsed time.Duration) {
+	if total <= 0 {
+		fmt.Fprintf(t.W, "\r%s %s...", terminal.Red("Downloading"), id)
+		return
+	}
+	pct := float64(bytes) / float64(total) * 100
+	fmt.Fprintf(t.W, "\r%s %s... %.1f%%", terminal.Red("Downloading"), id, pct)
+}
+
+func (t TTY) Done(id, file string) {
+	fmt.Fprintf(t.W, "\r%s %q\n", terminal.Green("Downloaded"), file)
+}
+
+func (t TTY) Error(id string, err error) {
+	fmt.Fprintf(t.W, "\r%s %s: %s\n", terminal.Red("Failed"), id, err)
+}
@@ -0,0 +1,87 @@
+// Copyright © 2020 Harrison Brown harrybrown98@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONEmitsOneTickPerLine(t *testing.T) {
+	var buf bytes.Buffer
+	j := JSON{W: &buf}
+
+	j.Start("abc")
+	j.Progress("abc", 50, 100, 2*time.Second)
+	j.Done("abc", "abc.mp4")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 NDJSON lines, got %d: %q", len(lines), buf.String())
+	}
+
+	var start, progress, done Tick
+	if err := json.Unmarshal([]byte(lines[0]), &start); err != nil {
+		t.Fatalf("unmarshal start tick: %v", err)
+	}
+	if start.Event != EventStart || start.ID != "abc" {
+		t.Errorf("start tick = %+v, want event=%q id=%q", start, EventStart, "abc")
+	}
+
+	if err := json.Unmarshal([]byte(lines[1]), &progress); err != nil {
+		t.Fatalf("unmarshal progress tick: %v", err)
+	}
+	if progress.Event != EventProgress || progress.Bytes != 50 || progress.Total != 100 || progress.Elapsed != 2 {
+		t.Errorf("progress tick = %+v, want bytes=50 total=100 elapsed=2", progress)
+	}
+
+	if err := json.Unmarshal([]byte(lines[2]), &done); err != nil {
+		t.Fatalf("unmarshal done tick: %v", err)
+	}
+	if done.Event != EventDone || done.File != "abc.mp4" {
+		t.Errorf("done tick = %+v, want event=%q file=%q", done, EventDone, "abc.mp4")
+	}
+}
+
+func TestJSONErrorOmitsZeroFields(t *testing.T) {
+	var buf bytes.Buffer
+	JSON{W: &buf}.Error("abc", errNotFound{})
+
+	var tick Tick
+	if err := json.Unmarshal(buf.Bytes(), &tick); err != nil {
+		t.Fatalf("unmarshal error tick: %v", err)
+	}
+	if tick.Event != EventError || tick.Error != "not found" {
+		t.Errorf("error tick = %+v, want event=%q error=%q", tick, EventError, "not found")
+	}
+	if strings.Contains(buf.String(), `"bytes"`) {
+		t.Errorf("expected omitempty fields to be absent, got %q", buf.String())
+	}
+}
+
+type errNotFound struct{}
+
+func (errNotFound) Error() string { return "not found" }
+
+func TestQuietDoesNotPanic(t *testing.T) {
+	var q Quiet
+	q.Start("abc")
+	q.Progress("abc", 1, 2, time.Second)
+	q.Done("abc", "abc.mp4")
+	q.Error("abc", errNotFound{})
+}
@@ -0,0 +1,44 @@
+// Copyright © 2020 Harrison Brown harrybrown98@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package terminal holds the small ANSI escape-code helpers yt uses
+// to color interactive output and hide/show the cursor while
+// downloads are in progress.
+package terminal
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	colorRed   = "31"
+	colorGreen = "32"
+)
+
+// Red renders s in red.
+func Red(s string) string { return color(colorRed, s) }
+
+// Green renders s in green.
+func Green(s string) string { return color(colorGreen, s) }
+
+func color(code, s string) string {
+	return fmt.Sprintf("\x1b[%sm%s\x1b[0m", code, s)
+}
+
+// CursorOff hides the terminal cursor.
+func CursorOff() { fmt.Fprint(os.Stdout, "\x1b[?25l") }
+
+// CursorOn shows the terminal cursor.
+func CursorOn() { fmt.Fprint(os.Stdout, "\x1b[?25h") }
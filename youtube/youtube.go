@@ -0,0 +1,366 @@
+// Copyright © 2020 Harrison Brown harrybrown98@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package youtube fetches metadata for youtube videos, playlists, and
+// channels, and downloads a video's streams to disk.
+package youtube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/harrybrwn/yt/pkg/ratelimit"
+)
+
+const (
+	videoInfoURL     = "https://www.youtube.com/get_video_info?video_id=%s&html5=1"
+	playlistURL      = "https://www.youtube.com/playlist?list=%s"
+	channelVideosURL = "https://www.youtube.com/channel/%s/videos"
+)
+
+// Video holds the metadata needed to download a single youtube video.
+type Video struct {
+	ID       string
+	Title    string
+	Author   string
+	FileName string
+
+	pr *playerResponse
+}
+
+// NewVideo fetches metadata for id and returns a Video ready to
+// download.
+func NewVideo(id string) (*Video, error) {
+	info, err := GetInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	pr, err := parsePlayerResponse(info)
+	if err != nil {
+		return nil, err
+	}
+	title := pr.VideoDetails.Title
+	if title == "" {
+		title = id
+	}
+	return &Video{
+		ID:       id,
+		Title:    title,
+		Author:   pr.VideoDetails.Author,
+		FileName: sanitizeFileName(title),
+		pr:       pr,
+	}, nil
+}
+
+// GetInfo fetches the raw get_video_info response for id, keyed by
+// field name the way url.Values does.
+func GetInfo(id string) (map[string][][]byte, error) {
+	resp, err := http.Get(fmt.Sprintf(videoInfoURL, url.QueryEscape(id)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("youtube: no info found for video %q", id)
+	}
+	info := make(map[string][][]byte, len(values))
+	for k, vs := range values {
+		for _, v := range vs {
+			info[k] = append(info[k], []byte(v))
+		}
+	}
+	return info, nil
+}
+
+// playerResponse is the subset of YouTube's player_response JSON blob
+// that yt needs: the video's title/author and its available streams.
+type playerResponse struct {
+	VideoDetails struct {
+		Title  string `json:"title"`
+		Author string `json:"author"`
+	} `json:"videoDetails"`
+	StreamingData struct {
+		Formats         []rawFormat `json:"formats"`
+		AdaptiveFormats []rawFormat `json:"adaptiveFormats"`
+	} `json:"streamingData"`
+}
+
+type rawFormat struct {
+	Itag          int    `json:"itag"`
+	URL           string `json:"url"`
+	MimeType      string `json:"mimeType"`
+	Bitrate       int64  `json:"bitrate"`
+	ContentLength string `json:"contentLength"`
+	QualityLabel  string `json:"qualityLabel"`
+	Quality       string `json:"quality"`
+	AudioQuality  string `json:"audioQuality"`
+}
+
+// parsePlayerResponse decodes the "player_response" field out of a
+// GetInfo result.
+func parsePlayerResponse(info map[string][][]byte) (*playerResponse, error) {
+	raw, ok := info["player_response"]
+	if !ok || len(raw) == 0 {
+		return nil, fmt.Errorf("youtube: no player_response in video info")
+	}
+	var pr playerResponse
+	if err := json.Unmarshal(raw[0], &pr); err != nil {
+		return nil, fmt.Errorf("youtube: parsing player_response: %w", err)
+	}
+	return &pr, nil
+}
+
+var invalidFileChars = regexp.MustCompile(`[\\/:*?"<>|]`)
+
+// sanitizeFileName strips characters that are invalid in a file name
+// on common filesystems.
+func sanitizeFileName(name string) string {
+	return strings.TrimSpace(invalidFileChars.ReplaceAllString(name, ""))
+}
+
+var videoIDPattern = regexp.MustCompile(`"videoId":"([a-zA-Z0-9_-]{11})"`)
+
+// PlaylistVideos fetches the video ids that belong to playlist id, in
+// the order YouTube lists them.
+func PlaylistVideos(id string) ([]string, error) {
+	return scrapeVideoIDs(fmt.Sprintf(playlistURL, url.QueryEscape(id)))
+}
+
+// ChannelVideos fetches the video ids uploaded by channel id.
+func ChannelVideos(id string) ([]string, error) {
+	return scrapeVideoIDs(fmt.Sprintf(channelVideosURL, url.QueryEscape(id)))
+}
+
+// scrapeVideoIDs pulls every distinct video id out of the rendered
+// page at u, preserving first-seen order.
+func scrapeVideoIDs(u string) ([]string, error) {
+	resp, err := http.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var ids []string
+	for _, m := range videoIDPattern.FindAllSubmatch(body, -1) {
+		id := string(m[1])
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("youtube: no videos found at %s", u)
+	}
+	return ids, nil
+}
+
+// ProgressFunc is called as a download streams in, reporting the
+// number of bytes written so far and the total size if known (0 if
+// not). It may be nil.
+type ProgressFunc func(bytes, total int64)
+
+// Format describes a single downloadable stream for a video, as
+// listed by `yt formats`.
+type Format struct {
+	Itag          int
+	Quality       string
+	Codecs        string
+	Bitrate       int64
+	ContentLength int64
+	MimeType      string
+	HasAudio      bool
+}
+
+// GetFormats fetches every stream (progressive and adaptive) listed
+// in id's player_response.
+func GetFormats(id string) ([]Format, error) {
+	info, err := GetInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	pr, err := parsePlayerResponse(info)
+	if err != nil {
+		return nil, err
+	}
+	raw := append(append([]rawFormat{}, pr.StreamingData.Formats...), pr.StreamingData.AdaptiveFormats...)
+	formats := make([]Format, len(raw))
+	for i, f := range raw {
+		formats[i] = f.toFormat()
+	}
+	return formats, nil
+}
+
+// toFormat converts a rawFormat parsed straight out of player_response
+// into the Format shape exposed to callers.
+func (f rawFormat) toFormat() Format {
+	length, _ := strconv.ParseInt(f.ContentLength, 10, 64)
+	quality := f.QualityLabel
+	if quality == "" {
+		quality = f.Quality
+	}
+	return Format{
+		Itag:          f.Itag,
+		Quality:       quality,
+		Codecs:        codecsFromMimeType(f.MimeType),
+		Bitrate:       f.Bitrate,
+		ContentLength: length,
+		MimeType:      f.MimeType,
+		HasAudio:      f.AudioQuality != "" || strings.HasPrefix(f.MimeType, "audio/"),
+	}
+}
+
+// codecsFromMimeType extracts the codecs parameter out of a mime type
+// like `video/mp4; codecs="avc1.640028, mp4a.40.2"`.
+func codecsFromMimeType(mimeType string) string {
+	const marker = `codecs="`
+	i := strings.Index(mimeType, marker)
+	if i < 0 {
+		return ""
+	}
+	rest := mimeType[i+len(marker):]
+	if j := strings.Index(rest, `"`); j >= 0 {
+		return rest[:j]
+	}
+	return rest
+}
+
+// Download saves v's video stream to path, reporting progress to
+// progress as it downloads and capping bandwidth with limiter (which
+// may be nil for no cap). A non-zero itag downloads that exact
+// format; itag of 0 picks a reasonable default.
+func (v *Video) Download(path string, progress ProgressFunc, limiter *ratelimit.Limiter, itag int) error {
+	f, err := v.format(itag, false)
+	if err != nil {
+		return err
+	}
+	return downloadTo(path, f.URL, progress, limiter)
+}
+
+// DownloadAudio saves v's audio stream to path, reporting progress to
+// progress as it downloads and capping bandwidth with limiter (which
+// may be nil for no cap). A non-zero itag downloads that exact
+// format; itag of 0 picks a reasonable default.
+func (v *Video) DownloadAudio(path string, progress ProgressFunc, limiter *ratelimit.Limiter, itag int) error {
+	f, err := v.format(itag, true)
+	if err != nil {
+		return err
+	}
+	return downloadTo(path, f.URL, progress, limiter)
+}
+
+// format resolves itag to a stream, falling back to defaultFormat
+// when itag is 0.
+func (v *Video) format(itag int, audioOnly bool) (rawFormat, error) {
+	if itag == 0 {
+		return v.defaultFormat(audioOnly)
+	}
+	for _, f := range append(v.pr.StreamingData.Formats, v.pr.StreamingData.AdaptiveFormats...) {
+		if f.Itag == itag {
+			return f, nil
+		}
+	}
+	return rawFormat{}, fmt.Errorf("youtube: no format with itag %d for %s", itag, v.ID)
+}
+
+// defaultFormat picks a reasonable stream when the caller hasn't
+// asked for a specific itag: the first progressive (combined
+// video+audio) format for video, or the first adaptive audio-only
+// format for audio.
+func (v *Video) defaultFormat(audioOnly bool) (rawFormat, error) {
+	if audioOnly {
+		for _, f := range v.pr.StreamingData.AdaptiveFormats {
+			if f.AudioQuality != "" && strings.HasPrefix(f.MimeType, "audio/") {
+				return f, nil
+			}
+		}
+		return rawFormat{}, fmt.Errorf("youtube: no audio-only format available for %s", v.ID)
+	}
+	if len(v.pr.StreamingData.Formats) == 0 {
+		return rawFormat{}, fmt.Errorf("youtube: no progressive format available for %s", v.ID)
+	}
+	return v.pr.StreamingData.Formats[0], nil
+}
+
+// downloadTo streams streamURL to a new file at path, reporting
+// progress to progress if non-nil and capping throughput with limiter
+// if non-nil.
+func downloadTo(path, streamURL string, progress ProgressFunc, limiter *ratelimit.Limiter) error {
+	if streamURL == "" {
+		return fmt.Errorf("youtube: format has no url")
+	}
+	resp, err := http.Get(streamURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("youtube: download failed with status %d", resp.StatusCode)
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	var src io.Reader = ratelimit.NewReader(context.Background(), resp.Body, limiter)
+	if progress != nil {
+		total := resp.ContentLength
+		if total < 0 { // unknown length
+			total = 0
+		}
+		src = &progressReader{r: src, total: total, progress: progress}
+	}
+	_, err = io.Copy(out, src)
+	return err
+}
+
+// progressReader wraps an io.Reader, invoking progress with the
+// running byte count after every Read.
+type progressReader struct {
+	r        io.Reader
+	total    int64
+	read     int64
+	progress ProgressFunc
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	if n > 0 {
+		p.read += int64(n)
+		p.progress(p.read, p.total)
+	}
+	return n, err
+}